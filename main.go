@@ -1,20 +1,23 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
-	"go/parser"
-	"go/token"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/1pkg/gopium/gopium"
 	"github.com/1pkg/gopium/runners"
 	"github.com/spf13/cobra"
+	"golang.org/x/tools/go/packages"
+	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -25,10 +28,15 @@ var (
 	tarch     string
 	tcpulines []int
 	// package parser vars
-	ppath   string
-	pbenvs  []string
-	pbflags []string
+	ppath     string
+	pbenvs    []string
+	pbflags   []string
+	pgoos     string
+	pgoarch   string
+	ptags     []string
+	pexcludes []string
 	// gopium walker vars
+	wmode    string
 	wregex   string
 	wdeep    bool
 	wbackref bool
@@ -37,57 +45,433 @@ var (
 	ptabwidth int
 	pusespace bool
 	pusegofmt bool
+	pcheck    bool
 	// gopium global vars
 	timeout     int
 	packageName string
 	batchSize   int
+	report      string
+	config      string
 )
 
 const ConfigKey = "x-config"
 
+// walkerAstGo is the default gopium walker. Its generics tolerance comes
+// from gopium's own internal parser (typepkg.ParserXToolPackagesAst,
+// packages.LoadAllSyntax), not from anything in this file, so no separate
+// generics-specific walker is needed here.
+const walkerAstGo = "ast_go"
+
 type Config struct {
 	packageName string
 	ppath       string
+	profile     Profile
+}
+
+// Profile describes one named scan run: its target platform, walker
+// tuning, strategy pipeline and which discovered packages it applies to.
+// A bare invocation (no --config) materialises a single implicit Profile
+// from the regular -c/-a/-l/... flags, so profiles × packages is always
+// the unit of work the worker pool iterates over.
+type Profile struct {
+	Name         string   `yaml:"name"`
+	Compiler     string   `yaml:"compiler"`
+	Arch         string   `yaml:"arch"`
+	CPULines     []int    `yaml:"cpu_lines"`
+	Walker       string   `yaml:"walker"`
+	WalkerRegexp string   `yaml:"walker_regexp"`
+	Strategies   []string `yaml:"strategies"`
+	Include      []string `yaml:"include"`
+	Exclude      []string `yaml:"exclude"`
+}
+
+// FileConfig is the top level shape of a --config gopium.yaml file.
+type FileConfig struct {
+	Profiles []Profile `yaml:"profiles"`
+}
+
+// implicitProfile materialises the single profile implied by the plain
+// -c/-a/-l/-r/-m flags plus the positional strategies, used whenever
+// --config isn't supplied.
+func implicitProfile() Profile {
+	return Profile{
+		Name:         "default",
+		Compiler:     tcompiler,
+		Arch:         tarch,
+		CPULines:     tcpulines,
+		Walker:       wmode,
+		WalkerRegexp: wregex,
+		Strategies:   cli.Flags().Args(),
+	}
+}
+
+// resolveProfiles loads the profiles to run: either every profile
+// declared in --config, or the single profile implied by the plain flags.
+func resolveProfiles() ([]Profile, error) {
+	if config == "" {
+		return []Profile{implicitProfile()}, nil
+	}
+	data, err := os.ReadFile(config)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	var fc FileConfig
+	if err := yaml.Unmarshal(data, &fc); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	if len(fc.Profiles) == 0 {
+		return nil, fmt.Errorf("config %q defines no profiles", config)
+	}
+	return fc.Profiles, nil
+}
+
+// globMatch reports whether the slash separated name matches pattern,
+// where pattern may contain "**" segments matching zero or more path
+// segments, on top of the usual filepath.Match segment wildcards.
+func globMatch(pattern, name string) bool {
+	return globMatchParts(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func globMatchParts(patterns, parts []string) bool {
+	if len(patterns) == 0 {
+		return len(parts) == 0
+	}
+	if patterns[0] == "**" {
+		if globMatchParts(patterns[1:], parts) {
+			return true
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		return globMatchParts(patterns, parts[1:])
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, err := filepath.Match(patterns[0], parts[0]); err != nil || !ok {
+		return false
+	}
+	return globMatchParts(patterns[1:], parts[1:])
+}
+
+// discoveredPackage is one scan unit: a package name/directory pair plus
+// its path relative to the scan root, used to match profile include/exclude globs.
+type discoveredPackage struct {
+	Name string
+	Dir  string
+	Rel  string
+}
+
+// discoverPackages loads every Go package under dir using go/packages, so
+// discovery honours the package's own build constraints (build tags,
+// GOOS/GOARCH suffixed files) instead of naively picking one .go file per
+// directory. Packages whose relative path matches any of excludes
+// (e.g. "vendor/**", "**/testdata/**") are dropped from the result.
+func discoverPackages(ctx context.Context, dir string, excludes []string) ([]discoveredPackage, error) {
+	env := append(os.Environ(), pbenvs...)
+	if pgoos != "" {
+		env = append(env, "GOOS="+pgoos)
+	}
+	if pgoarch != "" {
+		env = append(env, "GOARCH="+pgoarch)
+	}
+	buildFlags := append([]string{}, pbflags...)
+	if len(ptags) > 0 {
+		buildFlags = append(buildFlags, "-tags="+strings.Join(ptags, ","))
+	}
+	cfg := &packages.Config{
+		Context:    ctx,
+		Mode:       packages.NeedName | packages.NeedFiles,
+		Dir:        dir,
+		Env:        env,
+		BuildFlags: buildFlags,
+		Tests:      true,
+	}
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		return nil, fmt.Errorf("load packages: %w", err)
+	}
+	filtered := make([]discoveredPackage, 0, len(pkgs))
+	seen := make(map[string]bool, len(pkgs))
+	for _, pkg := range pkgs {
+		if len(pkg.GoFiles) == 0 {
+			continue
+		}
+		// Tests:true also synthesises a ".test" binary driver package per
+		// tested package, rooted under GOCACHE rather than the repo tree;
+		// it isn't a real scan target and must never reach runners.NewCli
+		if isTestBinaryPkg(pkg.PkgPath) {
+			continue
+		}
+		pdir := filepath.Dir(pkg.GoFiles[0])
+		rel, err := filepath.Rel(dir, pdir)
+		if err != nil {
+			rel = pkg.PkgPath
+		}
+		rel = filepath.ToSlash(rel)
+		if matchesAny(excludes, rel) {
+			continue
+		}
+		// Tests:true makes go/packages also return each tested package's
+		// synthetic in-module test variant under the same Dir; keep only
+		// the first entry per directory so a package isn't scanned twice
+		if seen[pdir] {
+			continue
+		}
+		seen[pdir] = true
+		filtered = append(filtered, discoveredPackage{Name: pkg.Name, Dir: pdir, Rel: rel})
+	}
+	return filtered, nil
+}
+
+// isTestBinaryPkg reports whether pkgPath names the synthetic ".test"
+// binary driver go/packages synthesises for a tested package under
+// Tests:true, rather than a real, scannable package.
+func isTestBinaryPkg(pkgPath string) bool {
+	return strings.HasSuffix(pkgPath, ".test")
 }
 
-func getPackageName(file string) (string, error) {
-	fset := token.NewFileSet()
+// matchesAny reports whether rel matches any of the given glob patterns.
+// An empty patterns list matches nothing.
+func matchesAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if globMatch(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
 
-	// parse the go soure file, but only the package clause
-	astFile, err := parser.ParseFile(fset, file, nil, parser.PackageClauseOnly)
+// scanResult captures the outcome of scanning a single discovered package,
+// used both to aggregate errors and to emit the --report JSON summary.
+type scanResult struct {
+	Profile    string   `json:"profile"`
+	Package    string   `json:"package"`
+	Path       string   `json:"path"`
+	Strategies []string `json:"strategies"`
+	ElapsedMS  int64    `json:"elapsed_ms"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// scanErrors aggregates per-package scan failures into a single error,
+// so main can report one non-zero exit code while still surfacing every
+// package that failed.
+type scanErrors []scanResult
+
+func (e scanErrors) Error() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%d package(s) failed to scan:\n", len(e))
+	for _, r := range e {
+		fmt.Fprintf(b, "- %s (%s): %s\n", r.Package, r.Path, r.Error)
+	}
+	return b.String()
+}
+
+// writeReport marshals results as JSON to the given path.
+func writeReport(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
-		return "", fmt.Errorf("parse file: %w", err)
+		return fmt.Errorf("marshal report: %w", err)
 	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	return nil
+}
+
+// DiffRecord is one file-level entry emitted in --check mode. gopium's
+// printer (runners.NewCli) has no dry-run mode of its own, so --check
+// lets the normal pipeline rewrite files as usual, diffs the result
+// against a pre-run snapshot, then restores the original bytes - the
+// tree is left untouched either way, only the diff is reported.
+//
+// This is file granularity, not the struct-level before/after size,
+// alignment and cache-line-crossing counts a real printer hook could
+// report; gopium exposes no API to this CLI for deriving those.
+type DiffRecord struct {
+	Package    string   `json:"package"`
+	File       string   `json:"file"`
+	Diff       string   `json:"diff"`
+	Strategies []string `json:"strategies"`
+}
 
-	if astFile.Name == nil {
-		return "", fmt.Errorf("no package name found")
+// fileSnapshot is a Go file's contents captured before the printer runs,
+// so --check can restore it afterwards.
+type fileSnapshot struct {
+	path string
+	data []byte
+}
+
+// dirLocks serializes scans that touch the same package directory, since
+// gopium's printer rewrites files in place: two profiles with no include
+// filter (e.g. comparing amd64 vs arm64 layouts in one pass) can cover the
+// same package, and without this their goroutines would race reading,
+// rewriting and (in --check mode) restoring the same files concurrently.
+var dirLocks sync.Map // map[string]*sync.Mutex
+
+// lockDir acquires the per-directory lock for dir and returns a function
+// that releases it.
+func lockDir(dir string) func() {
+	v, _ := dirLocks.LoadOrStore(dir, &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// snapshotGoFiles reads every top level *.go file in dir, mirroring the
+// set of files a single gopium package run can rewrite.
+func snapshotGoFiles(dir string) ([]fileSnapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read dir: %w", err)
+	}
+	snaps := make([]fileSnapshot, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+		snaps = append(snaps, fileSnapshot{path: path, data: data})
 	}
+	return snaps, nil
+}
 
-	return astFile.Name.Name, nil
+// diffSnapshots re-reads each snapshotted file after the printer has run,
+// restores its original contents so --check never leaves the tree
+// modified, and returns one DiffRecord per file whose contents changed.
+func diffSnapshots(pkgName string, strategies []string, snaps []fileSnapshot) ([]DiffRecord, error) {
+	var records []DiffRecord
+	for _, snap := range snaps {
+		after, err := os.ReadFile(snap.path)
+		if err != nil {
+			return records, fmt.Errorf("read %s: %w", snap.path, err)
+		}
+		if !bytes.Equal(snap.data, after) {
+			records = append(records, DiffRecord{
+				Package:    pkgName,
+				File:       snap.path,
+				Diff:       unifiedDiff(snap.path, string(snap.data), string(after)),
+				Strategies: strategies,
+			})
+		}
+		if err := os.WriteFile(snap.path, snap.data, 0644); err != nil {
+			return records, fmt.Errorf("restore %s: %w", snap.path, err)
+		}
+	}
+	return records, nil
+}
+
+// unifiedDiff renders a compact unified diff between before and after,
+// via a line level LCS diff. No external dependency is used since this
+// binary has no other diffing code to share.
+func unifiedDiff(path, before, after string) string {
+	a := strings.Split(before, "\n")
+	b := strings.Split(after, "\n")
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "--- %s\n", path)
+	fmt.Fprintf(&buf, "+++ %s\n", path)
+	for _, op := range lcsDiff(a, b) {
+		switch op.kind {
+		case diffDelete:
+			fmt.Fprintf(&buf, "-%s\n", op.line)
+		case diffInsert:
+			fmt.Fprintf(&buf, "+%s\n", op.line)
+		}
+	}
+	return buf.String()
+}
+
+type diffKind int
+
+const (
+	diffEqual diffKind = iota
+	diffDelete
+	diffInsert
+)
+
+type diffOp struct {
+	kind diffKind
+	line string
+}
+
+// lcsDiff computes a minimal line level diff between a and b via the
+// classic longest-common-subsequence dynamic program.
+func lcsDiff(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	dp := make([][]int, n+1)
+	for i := range dp {
+		dp[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				dp[i][j] = dp[i+1][j+1] + 1
+			case dp[i+1][j] >= dp[i][j+1]:
+				dp[i][j] = dp[i+1][j]
+			default:
+				dp[i][j] = dp[i][j+1]
+			}
+		}
+	}
+	ops := make([]diffOp, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{diffEqual, a[i]})
+			i++
+			j++
+		case dp[i+1][j] >= dp[i][j+1]:
+			ops = append(ops, diffOp{diffDelete, a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{diffInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, diffOp{diffDelete, a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, diffOp{diffInsert, b[j]})
+	}
+	return ops
 }
 
 func run(ctx context.Context, args []string) error {
 	ourConfig := ctx.Value(ConfigKey).(Config)
 	packageName = ourConfig.packageName
 	ppath = ourConfig.ppath
-	fmt.Println("processing >>", packageName, ppath, args)
+	profile := ourConfig.profile
+	fmt.Println("processing >>", profile.Name, packageName, ppath, profile.Strategies)
+	// resolve walker mode: an explicit profile/-m walker always wins,
+	// otherwise fall back to the default ast_go walker
+	walker := profile.Walker
+	if walker == "" {
+		walker = walkerAstGo
+	}
 	// create cli app instance
 	cli, err := runners.NewCli(
 		// target platform vars
-		tcompiler,
-		tarch,
-		tcpulines,
+		profile.Compiler,
+		profile.Arch,
+		profile.CPULines,
 		// package parser vars
 		packageName, // package name
 		ppath,
 		pbenvs,
 		pbflags,
 		// gopium walker vars
-		"ast_go", // single walker
-		wregex,
+		walker,
+		profile.WalkerRegexp,
 		wdeep,
 		wbackref,
-		args, // strategies slice
+		profile.Strategies, // strategies slice
 		// gopium printer vars
 		pindent,
 		ptabwidth,
@@ -169,6 +553,46 @@ Template {{package}} part is replaced with package name.
 		[]string{},
 		"Gopium go package build flags, additional list of building flags is expected.",
 	)
+	// set package_goos flag
+	cli.Flags().StringVar(
+		&pgoos,
+		"goos",
+		"",
+		"Gopium target GOOS used to discover packages, overrides the env GOOS when set.",
+	)
+	// set package_goarch flag
+	cli.Flags().StringVar(
+		&pgoarch,
+		"goarch",
+		"",
+		"Gopium target GOARCH used to discover packages, overrides the env GOARCH when set.",
+	)
+	// set package_build_tags flag
+	cli.Flags().StringSliceVar(
+		&ptags,
+		"tags",
+		[]string{},
+		"Gopium go build tags used to discover packages, passed through as -tags to go/packages.",
+	)
+	// set package_exclude_globs flag
+	cli.Flags().StringSliceVar(
+		&pexcludes,
+		"exclude",
+		[]string{"vendor/**", "**/testdata/**"},
+		"Gopium package exclude glob patterns, matched against each discovered package's relative path.",
+	)
+	// set walker flag
+	cli.Flags().StringVarP(
+		&wmode,
+		"walker",
+		"m",
+		"",
+		`
+Gopium walker mode, possible values are: ast_go, ast_std, ast_go_tree, ast_gopium.
+If left empty the default ast_go walker is used; its generics tolerance comes from
+gopium's own internal parser, not from this CLI.
+		`,
+	)
 	// set walker_regexp flag
 	cli.Flags().StringVarP(
 		&wregex,
@@ -237,6 +661,25 @@ Gopium printer use gofmt flag, flag that defines if canonical gofmt tool should
 By default it is used and overrides other printer formatting parameters.
 `,
 	)
+	// set check flag
+	cli.Flags().BoolVar(
+		&pcheck,
+		"check",
+		false,
+		`
+Gopium check flag, flag that runs the normal scan but snapshots each package's files first and
+restores them afterwards: any file the printer would have rewritten is reported as a unified diff
+instead, and the process exits non-zero if any file would change, so this can be dropped into CI
+the way gofmt -l or go vet are.
+		`,
+	)
+	// set diff-only flag as an alias of --check
+	cli.Flags().BoolVar(
+		&pcheck,
+		"diff-only",
+		false,
+		"Alias of --check.",
+	)
 	// set timeout flag
 	cli.Flags().IntVarP(
 		&timeout,
@@ -252,6 +695,25 @@ By default it is used and overrides other printer formatting parameters.
 		1,
 		"Number of file to scan in parallel",
 	)
+	// set report flag
+	cli.Flags().StringVar(
+		&report,
+		"report",
+		"",
+		"Gopium JSON report file path, if set a machine-readable summary of every scanned package is written there; with --check the diff records are written there instead.",
+	)
+	// set config flag
+	cli.Flags().StringVar(
+		&config,
+		"config",
+		"",
+		`
+Gopium multi-run config file path (e.g. gopium.yaml), describing named profiles that each pick
+their own target compiler/arch/cache-line sizes, walker and strategy pipeline, plus an
+include/exclude list of package globs. When set it replaces the single profile implied
+by the -c/-a/-l/-r/-m flags and positional strategies with every profile it declares.
+		`,
+	)
 }
 
 func main() {
@@ -266,67 +728,143 @@ func main() {
 		return run(ctx, args)
 	}
 
-	firstFile := make(map[string]string)
-	err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+	profiles, err := resolveProfiles()
+	if err != nil {
+		panic(err)
+	}
+	if batchSize < 1 {
+		panic(fmt.Errorf("batch_size must be >= 1, got %d", batchSize))
+	}
+
+	// bounded worker pool: sem caps in-flight goroutines at batchSize,
+	// a single wg.Wait() below ensures every profile × package is awaited
+	// regardless of whether the total count is a multiple of batchSize
+	sem := make(chan struct{}, batchSize)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var results []scanResult
+	var diffs []DiffRecord
+
+	for _, profile := range profiles {
+		profile := profile
+		excludes := append(append([]string{}, pexcludes...), profile.Exclude...)
+		pkgs, err := discoverPackages(ctx, ".", excludes)
 		if err != nil {
-			fmt.Println(err)
-			return err
+			panic(err)
 		}
-		if strings.HasSuffix(path, ".go") {
-			absPath, e := filepath.Abs(path)
-			if e != nil {
-				fmt.Println(e)
-				return nil
-			}
-			paths := strings.Split(absPath, "/")
-			if len(paths) < 2 {
-				return nil
+		for _, dp := range pkgs {
+			if len(profile.Include) > 0 && !matchesAny(profile.Include, dp.Rel) {
+				continue
 			}
-			dir := strings.Join(paths[:len(paths)-1], "/")
-			if _, ok := firstFile[dir]; ok {
-				return nil
-			}
-			firstFile[dir] = path
-		}
+			dp := dp
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
 
-		return nil
-	})
-	if err != nil {
-		panic(err)
+				pctx := ctx
+				if timeout > 0 {
+					var pcancel context.CancelFunc
+					pctx, pcancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+					defer pcancel()
+				}
+
+				// serialize any other profile/package pairing that touches
+				// the same directory, so concurrent profiles covering the
+				// same package can't race reading/rewriting/restoring it
+				unlockDir := lockDir(dp.Dir)
+				defer unlockDir()
+
+				// in --check mode, snapshot the package's files up front so
+				// the normal (file-rewriting) pipeline can run unmodified
+				// below, and its effect can be diffed and reverted after
+				var snaps []fileSnapshot
+				if pcheck {
+					var snapErr error
+					snaps, snapErr = snapshotGoFiles(dp.Dir)
+					if snapErr != nil {
+						fmt.Println(snapErr)
+					}
+				}
+
+				start := time.Now()
+				var scanErr error
+				select {
+				case <-pctx.Done():
+					scanErr = pctx.Err()
+				default:
+					newCtx := context.WithValue(pctx, ConfigKey, Config{
+						packageName: dp.Name,
+						ppath:       dp.Dir,
+						profile:     profile,
+					})
+					scanErr = cli.ExecuteContext(newCtx)
+				}
+
+				res := scanResult{
+					Profile:    profile.Name,
+					Package:    dp.Name,
+					Path:       dp.Dir,
+					Strategies: profile.Strategies,
+					ElapsedMS:  time.Since(start).Milliseconds(),
+				}
+				if scanErr != nil {
+					fmt.Println(scanErr)
+					res.Error = scanErr.Error()
+				}
+
+				var pkgDiffs []DiffRecord
+				if pcheck && snaps != nil {
+					var diffErr error
+					pkgDiffs, diffErr = diffSnapshots(dp.Name, profile.Strategies, snaps)
+					if diffErr != nil {
+						fmt.Println(diffErr)
+					}
+				}
+
+				mu.Lock()
+				results = append(results, res)
+				diffs = append(diffs, pkgDiffs...)
+				mu.Unlock()
+			}()
+		}
 	}
-	var wg sync.WaitGroup
+	wg.Wait()
 
-	count := 0
-	for dir, filename := range firstFile {
-		dir := dir
-		filename := filename
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			pname, err := getPackageName(filename)
-			if err != nil {
+	// in --check mode the aggregated diff records are the report,
+	// merged here from every package's snapshot diff
+	if pcheck {
+		if report != "" {
+			if err := writeReport(report, diffs); err != nil {
 				fmt.Println(err)
-				return
 			}
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				newCtx := context.WithValue(ctx, ConfigKey, Config{
-					packageName: pname,
-					ppath:       dir,
-				})
-				if err := cli.ExecuteContext(newCtx); err != nil {
-					fmt.Println(err)
-					return
-				}
+		} else {
+			data, err := json.MarshalIndent(diffs, "", "  ")
+			if err != nil {
+				fmt.Println(err)
+			} else {
+				fmt.Println(string(data))
 			}
+		}
+	} else if report != "" {
+		if err := writeReport(report, results); err != nil {
+			fmt.Println(err)
+		}
+	}
 
-		}()
-		count += 1
-		if count == batchSize {
-			wg.Wait()
-			count = 0
+	var failed scanErrors
+	for _, res := range results {
+		if res.Error != "" {
+			failed = append(failed, res)
 		}
 	}
+	if len(failed) > 0 {
+		fmt.Println(failed.Error())
+		os.Exit(1)
+	}
+	if pcheck && len(diffs) > 0 {
+		fmt.Printf("%d file(s) would be modified\n", len(diffs))
+		os.Exit(1)
+	}
 }
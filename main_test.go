@@ -0,0 +1,211 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGlobMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"vendor/**", "vendor/foo/bar.go", true},
+		{"vendor/**", "foo/vendor/bar.go", false},
+		{"**/testdata/**", "pkg/testdata/fixture.go", true},
+		{"**/testdata/**", "pkg/testdataish/fixture.go", false},
+		{"**/testdata/**", "testdata/fixture.go", true},
+		{"pkg/*", "pkg/sub", true},
+		{"pkg/*", "pkg/sub/nested", false},
+	}
+	for _, c := range cases {
+		if got := globMatch(c.pattern, c.name); got != c.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", c.pattern, c.name, got, c.want)
+		}
+	}
+}
+
+func TestMatchesAny(t *testing.T) {
+	if matchesAny(nil, "pkg/foo") {
+		t.Error("matchesAny with no patterns should match nothing")
+	}
+	if !matchesAny([]string{"vendor/**", "pkg/**"}, "pkg/foo") {
+		t.Error("matchesAny should match rel against any pattern in the list")
+	}
+	if matchesAny([]string{"vendor/**"}, "pkg/foo") {
+		t.Error("matchesAny should not match unrelated patterns")
+	}
+}
+
+func TestLockDirSerializesSameDirectory(t *testing.T) {
+	dir := t.TempDir()
+	var active int32
+	var maxActive int32
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := lockDir(dir)
+			defer unlock()
+
+			mu.Lock()
+			active++
+			if active > maxActive {
+				maxActive = active
+			}
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if maxActive != 1 {
+		t.Errorf("lockDir let %d goroutines into the same directory's critical section at once, want 1", maxActive)
+	}
+}
+
+func TestLockDirDoesNotSerializeDifferentDirectories(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	done := make(chan struct{})
+	unlockA := lockDir(a)
+	go func() {
+		unlockB := lockDir(b)
+		unlockB()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("lockDir serialized unrelated directories")
+	}
+	unlockA()
+}
+
+func TestLcsDiffIdentical(t *testing.T) {
+	lines := []string{"a", "b", "c"}
+	ops := lcsDiff(lines, lines)
+	for _, op := range ops {
+		if op.kind != diffEqual {
+			t.Fatalf("identical inputs produced a non-equal op: %+v", op)
+		}
+	}
+	if len(ops) != len(lines) {
+		t.Fatalf("got %d ops, want %d", len(ops), len(lines))
+	}
+}
+
+func TestLcsDiffInsertAndDelete(t *testing.T) {
+	before := []string{"a", "b", "c"}
+	after := []string{"a", "x", "c"}
+	ops := lcsDiff(before, after)
+
+	var deletes, inserts, equals int
+	for _, op := range ops {
+		switch op.kind {
+		case diffDelete:
+			deletes++
+		case diffInsert:
+			inserts++
+		case diffEqual:
+			equals++
+		}
+	}
+	if deletes != 1 || inserts != 1 || equals != 2 {
+		t.Errorf("lcsDiff(%v, %v) = %d deletes, %d inserts, %d equals, want 1, 1, 2", before, after, deletes, inserts, equals)
+	}
+}
+
+func TestUnifiedDiffOnlyReportsChanges(t *testing.T) {
+	before := "package p\n\ntype S struct {\n\tA int\n\tB int\n}\n"
+	after := "package p\n\ntype S struct {\n\tB int\n\tA int\n}\n"
+	diff := unifiedDiff("s.go", before, after)
+
+	if !strings.Contains(diff, "--- s.go") || !strings.Contains(diff, "+++ s.go") {
+		t.Errorf("unifiedDiff missing file headers: %q", diff)
+	}
+	if !strings.Contains(diff, "-\tA int") || !strings.Contains(diff, "+\tA int") {
+		t.Errorf("unifiedDiff(%q, %q) = %q, want the reordered field to show as a removal and an addition", before, after, diff)
+	}
+	if unifiedDiff("s.go", before, before) != "--- s.go\n+++ s.go\n" {
+		t.Errorf("unifiedDiff of identical content should report no changed lines")
+	}
+}
+
+func TestResolveProfilesImplicit(t *testing.T) {
+	config = ""
+	tcompiler = "gc"
+	tarch = "amd64"
+	tcpulines = []int{32, 64}
+	wmode = "ast_go"
+	wregex = ""
+
+	profiles, err := resolveProfiles()
+	if err != nil {
+		t.Fatalf("resolveProfiles() error = %v, want nil", err)
+	}
+	want := []Profile{implicitProfile()}
+	if !reflect.DeepEqual(profiles, want) {
+		t.Errorf("resolveProfiles() = %+v, want %+v", profiles, want)
+	}
+}
+
+func TestResolveProfilesFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "gopium.yaml")
+	data := []byte("profiles:\n  - name: amd64\n    compiler: gc\n    arch: amd64\n  - name: arm64\n    compiler: gc\n    arch: arm64\n")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config = path
+	defer func() { config = "" }()
+
+	profiles, err := resolveProfiles()
+	if err != nil {
+		t.Fatalf("resolveProfiles() error = %v, want nil", err)
+	}
+	if len(profiles) != 2 || profiles[0].Name != "amd64" || profiles[1].Name != "arm64" {
+		t.Errorf("resolveProfiles() = %+v, want profiles named amd64 and arm64", profiles)
+	}
+}
+
+func TestResolveProfilesEmptyConfigIsError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "gopium.yaml")
+	if err := os.WriteFile(path, []byte("profiles: []\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config = path
+	defer func() { config = "" }()
+
+	if _, err := resolveProfiles(); err == nil {
+		t.Error("resolveProfiles() with a config defining no profiles should error")
+	}
+}
+
+func TestIsTestBinaryPkg(t *testing.T) {
+	cases := []struct {
+		pkgPath string
+		want    bool
+	}{
+		{"example.com/foo", false},
+		{"example.com/foo.test", true},
+		{"example.com/foo [example.com/foo.test]", false},
+	}
+	for _, c := range cases {
+		if got := isTestBinaryPkg(c.pkgPath); got != c.want {
+			t.Errorf("isTestBinaryPkg(%q) = %v, want %v", c.pkgPath, got, c.want)
+		}
+	}
+}